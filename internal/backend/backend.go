@@ -0,0 +1,69 @@
+// Package backend abstracts over the places dircast can pull episode
+// audio from, so the feed generator doesn't need to know whether it's
+// talking to Dropbox, S3, a local directory, or a WebDAV share.
+package backend
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Entry is one file found by List.
+type Entry struct {
+	// ID uniquely identifies the file to the backend and is used as the
+	// feed's stable identifier (the GUID / {fileId} in /audio/{fileId}).
+	// It only needs to be unique within a single backend instance.
+	ID string
+	// Path addresses the file for a subsequent PublicURL or Open call.
+	Path string
+	// Name is the file's base name, e.g. "01 - Pilot.mp3".
+	Name string
+	// ContentHash is an opaque string that changes whenever the file's
+	// contents change. Backends without a native content hash can use
+	// size+mtime instead; it only needs to detect changes, not verify
+	// integrity.
+	ContentHash string
+	Size        uint64
+	ModTime     time.Time
+	// Deleted is set on entries returned by CursorLister.ListSince that
+	// represent a file having disappeared since cursor, so the caller can
+	// evict it without having to notice its absence from a full listing.
+	Deleted bool
+}
+
+// Backend is a source of episode audio files.
+type Backend interface {
+	// List returns the audio files found directly in path. Folders are
+	// not walked; see RecursiveLister for recursing into subfolders.
+	List(ctx context.Context, path string) ([]Entry, error)
+	// PublicURL returns a URL that can be embedded directly in the feed
+	// as an episode's enclosure. It may be long-lived (e.g. a Dropbox
+	// shared link) or permanent (e.g. an S3 object URL); server mode
+	// avoids relying on its lifetime by proxying through /audio/{fileId}
+	// instead.
+	PublicURL(ctx context.Context, path string) (string, error)
+	// Open streams the file at path starting at offset, for reading tag
+	// data via Range requests and for proxying audio in server mode.
+	Open(ctx context.Context, path string, offset int64) (io.ReadCloser, error)
+}
+
+// RecursiveLister is implemented by backends that can list a folder and
+// everything beneath it in one call. Entry.Path for results includes
+// the full subfolder structure below the listed path (e.g.
+// "MyPodcast/S02/03 - Title.mp3"), which feed uses to map episodes onto
+// itunes:season/itunes:episode numbers.
+type RecursiveLister interface {
+	ListRecursive(ctx context.Context, path string) ([]Entry, error)
+}
+
+// CursorLister is implemented by backends that can resume a listing from
+// a cursor returned by a previous call, instead of re-listing the whole
+// folder every time. ListSince behaves like List when cursor is empty
+// (a first run), and otherwise returns only what changed since cursor,
+// including Deleted entries for files that disappeared. The returned
+// nextCursor should be persisted and passed back in on the next call
+// either way.
+type CursorLister interface {
+	ListSince(ctx context.Context, path, cursor string) (entries []Entry, nextCursor string, err error)
+}
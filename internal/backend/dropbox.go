@@ -0,0 +1,199 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/sharing"
+)
+
+// Dropbox is the original backend this tool was built around: it lists
+// a Dropbox folder, mints shared links for the feed, and streams file
+// bytes via the regular download endpoint.
+type Dropbox struct {
+	Files   files.Client
+	Sharing sharing.Client
+}
+
+// NewDropbox returns a Backend backed by the given Dropbox API clients.
+func NewDropbox(dbxf files.Client, dbxs sharing.Client) *Dropbox {
+	return &Dropbox{Files: dbxf, Sharing: dbxs}
+}
+
+// List implements Backend.
+func (d *Dropbox) List(ctx context.Context, path string) ([]Entry, error) {
+	result, err := d.Files.ListFolder(files.NewListFolderArg(path))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for {
+		for _, e := range result.Entries {
+			if fm, ok := e.(*files.FileMetadata); ok {
+				entries = append(entries, Entry{
+					ID:          fm.Id,
+					Path:        fm.PathLower,
+					Name:        fm.Name,
+					ContentHash: fm.ContentHash,
+					Size:        fm.Size,
+					ModTime:     time.Time(fm.ServerModified),
+				})
+			}
+		}
+		if !result.HasMore {
+			break
+		}
+		result, err = d.Files.ListFolderContinue(files.NewListFolderContinueArg(result.Cursor))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// ListSince implements backend.CursorLister. With an empty cursor it
+// behaves like List; otherwise it resumes from cursor via
+// ListFolderContinue so repeated runs only pay for what changed,
+// surfacing removed files as Deleted entries so callers can evict them
+// without re-listing everything.
+func (d *Dropbox) ListSince(ctx context.Context, path, cursor string) ([]Entry, string, error) {
+	var result *files.ListFolderResult
+	var err error
+	if cursor == "" {
+		arg := files.NewListFolderArg(path)
+		// Without this, Dropbox suppresses DeletedMetadata for the
+		// lifetime of the cursor chain started here, so a later
+		// ListFolderContinue could never tell us a file was removed.
+		arg.IncludeDeleted = true
+		result, err = d.Files.ListFolder(arg)
+	} else {
+		result, err = d.Files.ListFolderContinue(files.NewListFolderContinueArg(cursor))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var entries []Entry
+	for {
+		for _, e := range result.Entries {
+			switch m := e.(type) {
+			case *files.FileMetadata:
+				entries = append(entries, Entry{
+					ID:          m.Id,
+					Path:        m.PathLower,
+					Name:        m.Name,
+					ContentHash: m.ContentHash,
+					Size:        m.Size,
+					ModTime:     time.Time(m.ServerModified),
+				})
+			case *files.DeletedMetadata:
+				entries = append(entries, Entry{Path: m.PathLower, Name: m.Name, Deleted: true})
+			}
+		}
+		if !result.HasMore {
+			break
+		}
+		result, err = d.Files.ListFolderContinue(files.NewListFolderContinueArg(result.Cursor))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return entries, result.Cursor, nil
+}
+
+// ListRecursive implements backend.RecursiveLister using
+// files/list_folder's Recursive option, paging via ListFolderContinue
+// until HasMore is false -- mirroring how rclone's Dropbox backend
+// recurses to avoid the path-casing pitfalls of listing one directory
+// at a time.
+func (d *Dropbox) ListRecursive(ctx context.Context, path string) ([]Entry, error) {
+	arg := files.NewListFolderArg(path)
+	arg.Recursive = true
+	result, err := d.Files.ListFolder(arg)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for {
+		for _, e := range result.Entries {
+			if fm, ok := e.(*files.FileMetadata); ok {
+				entries = append(entries, Entry{
+					ID:          fm.Id,
+					Path:        fm.PathLower,
+					Name:        fm.Name,
+					ContentHash: fm.ContentHash,
+					Size:        fm.Size,
+					ModTime:     time.Time(fm.ServerModified),
+				})
+			}
+		}
+		if !result.HasMore {
+			break
+		}
+		result, err = d.Files.ListFolderContinue(files.NewListFolderContinueArg(result.Cursor))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// PublicURL implements Backend by getting or creating a Dropbox shared
+// link and rewriting it into a direct-download URL.
+func (d *Dropbox) PublicURL(ctx context.Context, path string) (string, error) {
+	sharedLink, err := getOrCreateSharedLink(d.Sharing, path)
+	if err != nil {
+		return "", fmt.Errorf("creating or getting shared link: %w", err)
+	}
+	downloadURL := strings.Replace(sharedLink, "www.dropbox.com", "dl.dropboxusercontent.com", 1)
+	downloadURL = strings.Replace(downloadURL, "dl=0", "dl=1", 1)
+	return downloadURL, nil
+}
+
+// Open implements Backend using the regular Dropbox download endpoint,
+// passing offset through as a Range header.
+func (d *Dropbox) Open(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	arg := files.NewDownloadArg(path)
+	if offset > 0 {
+		arg.ExtraHeaders = map[string]string{"Range": fmt.Sprintf("bytes=%d-", offset)}
+	}
+	_, content, err := d.Files.Download(arg)
+	return content, err
+}
+
+// TemporaryLink mints a short-lived direct link to path, used by server
+// mode's /audio/{fileId} proxy instead of a long-lived shared link.
+func (d *Dropbox) TemporaryLink(path string) (string, error) {
+	result, err := d.Files.GetTemporaryLink(files.NewGetTemporaryLinkArg(path))
+	if err != nil {
+		return "", err
+	}
+	return result.Link, nil
+}
+
+func getOrCreateSharedLink(dbxs sharing.Client, path string) (string, error) {
+	arg := sharing.NewCreateSharedLinkArg(path)
+	link, err := dbxs.CreateSharedLink(arg)
+	if err != nil {
+		apiError, ok := err.(dropbox.APIError)
+		if ok && strings.HasPrefix(apiError.ErrorSummary, "shared_link_already_exists") {
+			listArg := sharing.NewListSharedLinksArg()
+			listArg.Path = path
+			links, err := dbxs.ListSharedLinks(listArg)
+			if err != nil {
+				return "", err
+			}
+			if len(links.Links) > 0 {
+				if sl, ok := links.Links[0].(*sharing.SharedLinkMetadata); ok {
+					return sl.Url, nil
+				}
+			}
+		}
+		return "", err
+	}
+	return link.Url, nil
+}
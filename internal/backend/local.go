@@ -0,0 +1,129 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local is a Backend that reads audio files from a directory on the
+// local filesystem. Since a bare filesystem path isn't reachable from
+// outside the machine, Local runs a small embedded HTTP server that
+// serves Dir as static files, and PublicURL points at that server.
+type Local struct {
+	Dir     string
+	baseURL string
+}
+
+// NewLocal returns a Backend rooted at dir. addr is the address the
+// embedded file server listens on (e.g. ":8081"); baseURL is the URL
+// other hosts can reach that server at (e.g. "http://my-host:8081"). If
+// addr is empty, no server is started and PublicURL returns an error --
+// useful when running behind dircast serve, which proxies audio itself
+// via Backend.Open and never calls PublicURL.
+func NewLocal(dir, addr, baseURL string) (*Local, error) {
+	l := &Local{Dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+	if addr == "" {
+		return l, nil
+	}
+	fileServer := http.FileServer(http.Dir(dir))
+	srv := &http.Server{Addr: addr, Handler: fileServer}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("local backend file server stopped: %s", err)
+		}
+	}()
+	return l, nil
+}
+
+// List implements Backend.
+func (l *Local) List(ctx context.Context, path string) ([]Entry, error) {
+	dirents, err := os.ReadDir(filepath.Join(l.Dir, path))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, d := range dirents {
+		if d.IsDir() {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil, err
+		}
+		relPath := filepath.ToSlash(filepath.Join(path, d.Name()))
+		entries = append(entries, Entry{
+			ID:          relPath,
+			Path:        relPath,
+			Name:        d.Name(),
+			ContentHash: fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()),
+			Size:        uint64(info.Size()),
+			ModTime:     info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+// ListRecursive implements backend.RecursiveLister by walking every
+// subdirectory of path.
+func (l *Local) ListRecursive(ctx context.Context, root string) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(filepath.Join(l.Dir, root), func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(l.Dir, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		entries = append(entries, Entry{
+			ID:          relPath,
+			Path:        relPath,
+			Name:        d.Name(),
+			ContentHash: fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()),
+			Size:        uint64(info.Size()),
+			ModTime:     info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PublicURL implements Backend, pointing at the embedded file server.
+func (l *Local) PublicURL(ctx context.Context, path string) (string, error) {
+	if l.baseURL == "" {
+		return "", fmt.Errorf("local backend: no base URL configured for its embedded file server")
+	}
+	return l.baseURL + "/" + strings.TrimPrefix(path, "/"), nil
+}
+
+// Open implements Backend by opening the file directly off disk.
+func (l *Local) Open(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(l.Dir, path))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
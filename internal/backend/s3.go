@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Config configures an S3 backend. Credentials and region are picked
+// up from the standard AWS environment variables/shared config unless
+// overridden here, so this tool works the same way the AWS CLI does.
+type S3Config struct {
+	Bucket string
+	Region string
+	// Endpoint overrides the default AWS endpoint, for S3-compatible
+	// services like MinIO, Backblaze B2, or R2.
+	Endpoint string
+	// PresignExpiry is how long PublicURL's presigned GET URLs remain
+	// valid. Defaults to 7 days if zero.
+	PresignExpiry time.Duration
+}
+
+// S3 is a Backend backed by an S3-compatible object store.
+type S3 struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	expiry  time.Duration
+}
+
+// NewS3 builds an S3 backend from cfg, resolving credentials via the
+// default AWS SDK config chain.
+func NewS3(ctx context.Context, cfg S3Config) (*S3, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(cfg.Region),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	expiry := cfg.PresignExpiry
+	if expiry == 0 {
+		expiry = 7 * 24 * time.Hour
+	}
+
+	return &S3{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  cfg.Bucket,
+		expiry:  expiry,
+	}, nil
+}
+
+// List implements Backend, treating path as a "/"-delimited prefix so
+// that, like the other backends, only the files directly inside it are
+// returned -- not the contents of any nested "subfolders".
+func (b *S3) List(ctx context.Context, path string) ([]Entry, error) {
+	prefix := strings.TrimSuffix(path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var entries []Entry
+	var continuationToken *string
+	for {
+		out, err := b.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(b.bucket),
+			Prefix:            aws.String(prefix),
+			Delimiter:         aws.String("/"),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing s3://%s/%s: %w", b.bucket, prefix, err)
+		}
+		for _, obj := range out.Contents {
+			key := aws.ToString(obj.Key)
+			entries = append(entries, Entry{
+				ID:          key,
+				Path:        key,
+				Name:        strings.TrimPrefix(key, prefix),
+				ContentHash: strings.Trim(aws.ToString(obj.ETag), `"`),
+				Size:        uint64(aws.ToInt64(obj.Size)),
+				ModTime:     aws.ToTime(obj.LastModified),
+			})
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		continuationToken = out.NextContinuationToken
+	}
+	return entries, nil
+}
+
+// ListRecursive implements backend.RecursiveLister by listing with no
+// delimiter, so every key under the prefix is returned regardless of
+// its depth.
+func (b *S3) ListRecursive(ctx context.Context, path string) ([]Entry, error) {
+	prefix := strings.TrimSuffix(path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var entries []Entry
+	var continuationToken *string
+	for {
+		out, err := b.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(b.bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing s3://%s/%s: %w", b.bucket, prefix, err)
+		}
+		for _, obj := range out.Contents {
+			key := aws.ToString(obj.Key)
+			entries = append(entries, Entry{
+				ID:          key,
+				Path:        key,
+				Name:        baseName(key),
+				ContentHash: strings.Trim(aws.ToString(obj.ETag), `"`),
+				Size:        uint64(aws.ToInt64(obj.Size)),
+				ModTime:     aws.ToTime(obj.LastModified),
+			})
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		continuationToken = out.NextContinuationToken
+	}
+	return entries, nil
+}
+
+// baseName returns the final path segment of an S3 key, e.g.
+// "Podcast/S02/03 - Title.mp3" -> "03 - Title.mp3". Unlike path.Base,
+// it doesn't collide with the "path" parameter name used throughout
+// this file's Backend methods.
+func baseName(key string) string {
+	if i := strings.LastIndexByte(key, '/'); i != -1 {
+		return key[i+1:]
+	}
+	return key
+}
+
+// PublicURL implements Backend by presigning a GET request.
+func (b *S3) PublicURL(ctx context.Context, path string) (string, error) {
+	req, err := b.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(path),
+	}, s3.WithPresignExpires(b.expiry))
+	if err != nil {
+		return "", fmt.Errorf("presigning s3://%s/%s: %w", b.bucket, path, err)
+	}
+	return req.URL, nil
+}
+
+// Open implements Backend.
+func (b *S3) Open(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(path),
+	}
+	if offset > 0 {
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	out, err := b.client.GetObject(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
@@ -0,0 +1,34 @@
+package backend
+
+import "testing"
+
+func TestBaseName(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{
+			name: "nested key",
+			key:  "MyPodcast/S02/03 - Title.mp3",
+			want: "03 - Title.mp3",
+		},
+		{
+			name: "top-level key",
+			key:  "MyPodcast/01 - Title.mp3",
+			want: "01 - Title.mp3",
+		},
+		{
+			name: "no slashes",
+			key:  "episode.mp3",
+			want: "episode.mp3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseName(tt.key); got != tt.want {
+				t.Errorf("baseName(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
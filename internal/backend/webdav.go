@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// WebDAVConfig configures a WebDAV backend.
+type WebDAVConfig struct {
+	// BaseURL is the WebDAV share's root, e.g. "https://dav.example.com/remote.php/webdav".
+	BaseURL  string
+	Username string
+	Password string
+}
+
+// WebDAV is a Backend that lists and streams files from a WebDAV share
+// using PROPFIND and GET, with no dependency beyond net/http.
+type WebDAV struct {
+	baseURL  *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAV returns a Backend for cfg.
+func NewWebDAV(cfg WebDAVConfig) (*WebDAV, error) {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing WebDAV base URL: %w", err)
+	}
+	return &WebDAV{baseURL: u, username: cfg.Username, password: cfg.Password, client: http.DefaultClient}, nil
+}
+
+// List implements Backend via a depth-1 PROPFIND.
+func (w *WebDAV) List(ctx context.Context, dirPath string) ([]Entry, error) {
+	return w.propfind(ctx, dirPath, "1")
+}
+
+// ListRecursive implements backend.RecursiveLister via a PROPFIND with
+// Depth: infinity. Not every WebDAV server honors infinite depth; those
+// that don't will return an error here instead of a partial listing.
+func (w *WebDAV) ListRecursive(ctx context.Context, dirPath string) ([]Entry, error) {
+	return w.propfind(ctx, dirPath, "infinity")
+}
+
+func (w *WebDAV) propfind(ctx context.Context, dirPath, depth string) ([]Entry, error) {
+	reqURL := w.resolve(dirPath)
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", reqURL, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml")
+	w.authenticate(req)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("webdav: PROPFIND %s returned %s", reqURL, resp.Status)
+	}
+
+	var ms multistatusRaw
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decoding PROPFIND response: %w", err)
+	}
+
+	var entries []Entry
+	for _, r := range ms.Responses {
+		if r.Prop.ResourceType.Collection != nil {
+			continue // skip directories themselves, at any depth
+		}
+		hrefPath, err := url.PathUnescape(r.Href)
+		if err != nil {
+			hrefPath = r.Href
+		}
+		relPath := relativeHref(w.baseURL.Path, hrefPath)
+		name := path.Base(strings.TrimSuffix(relPath, "/"))
+		size, _ := strconv.ParseUint(r.Prop.ContentLength, 10, 64)
+		modTime, _ := http.ParseTime(r.Prop.LastModified)
+		entries = append(entries, Entry{
+			ID:          relPath,
+			Path:        relPath,
+			Name:        name,
+			ContentHash: strings.Trim(r.Prop.ETag, `"`),
+			Size:        size,
+			ModTime:     modTime,
+		})
+	}
+	return entries, nil
+}
+
+// relativeHref strips basePath (the share's root path, e.g.
+// "/remote.php/webdav") from hrefPath (an absolute PROPFIND href, e.g.
+// "/remote.php/webdav/Podcast/ep.mp3"), so the result can be passed
+// back into resolve without doubling the prefix.
+func relativeHref(basePath, hrefPath string) string {
+	rel := strings.TrimPrefix(hrefPath, basePath)
+	return strings.TrimPrefix(rel, "/")
+}
+
+// PublicURL implements Backend by returning the resource's direct URL,
+// with basic-auth credentials embedded if configured.
+func (w *WebDAV) PublicURL(ctx context.Context, filePath string) (string, error) {
+	u, err := url.Parse(w.resolve(filePath))
+	if err != nil {
+		return "", err
+	}
+	if w.username != "" {
+		u.User = url.UserPassword(w.username, w.password)
+	}
+	return u.String(), nil
+}
+
+// Open implements Backend via a GET with a Range header.
+func (w *WebDAV) Open(ctx context.Context, filePath string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.resolve(filePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	w.authenticate(req)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("webdav: GET %s returned %s", filePath, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (w *WebDAV) authenticate(req *http.Request) {
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+}
+
+// resolve joins p onto the share's base URL.
+func (w *WebDAV) resolve(p string) string {
+	u := *w.baseURL
+	u.Path = path.Join(u.Path, p)
+	return u.String()
+}
+
+// multistatusRaw mirrors the actual XML shape (propstat>prop nesting
+// can't be expressed via a single chained xml tag on davResponse above,
+// so it's decoded separately).
+type multistatusRaw struct {
+	Responses []struct {
+		Href string `xml:"href"`
+		Prop struct {
+			DisplayName   string `xml:"prop>displayname"`
+			ContentLength string `xml:"prop>getcontentlength"`
+			LastModified  string `xml:"prop>getlastmodified"`
+			ETag          string `xml:"prop>getetag"`
+			ResourceType  struct {
+				Collection *struct{} `xml:"collection"`
+			} `xml:"prop>resourcetype"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:">
+  <D:prop>
+    <D:displayname/>
+    <D:getcontentlength/>
+    <D:getlastmodified/>
+    <D:getetag/>
+    <D:resourcetype/>
+  </D:prop>
+</D:propfind>
+`
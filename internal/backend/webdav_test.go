@@ -0,0 +1,38 @@
+package backend
+
+import "testing"
+
+func TestRelativeHref(t *testing.T) {
+	tests := []struct {
+		name     string
+		basePath string
+		hrefPath string
+		want     string
+	}{
+		{
+			name:     "nextcloud-style absolute href",
+			basePath: "/remote.php/webdav",
+			hrefPath: "/remote.php/webdav/Podcast/ep.mp3",
+			want:     "Podcast/ep.mp3",
+		},
+		{
+			name:     "base path with trailing slash",
+			basePath: "/remote.php/webdav/",
+			hrefPath: "/remote.php/webdav/Podcast/ep.mp3",
+			want:     "Podcast/ep.mp3",
+		},
+		{
+			name:     "share mounted at root",
+			basePath: "",
+			hrefPath: "/Podcast/ep.mp3",
+			want:     "Podcast/ep.mp3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relativeHref(tt.basePath, tt.hrefPath); got != tt.want {
+				t.Errorf("relativeHref(%q, %q) = %q, want %q", tt.basePath, tt.hrefPath, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package config loads a YAML file describing one or more podcasts for
+// dircast to generate feeds for, so a single dircast process can watch
+// several folders instead of just one.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/reillywatson/dircast/internal/feed"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the top-level shape of a dircast config file.
+type File struct {
+	// Backend selects the storage backend shared by every podcast
+	// below, e.g. "dropbox", "s3", "local", or "webdav".
+	Backend string `yaml:"backend,omitempty"`
+	// OutDir is where one-shot mode writes each podcast's feed file,
+	// named "<slug>.xml". Defaults to the current directory.
+	OutDir   string    `yaml:"out_dir,omitempty"`
+	Podcasts []Podcast `yaml:"podcasts"`
+}
+
+// Podcast describes a single podcast feed: where to find its episodes
+// and how to describe them in the generated RSS.
+type Podcast struct {
+	// Slug identifies this podcast in file names and server mode URLs,
+	// e.g. "/feeds/{slug}.xml". Must be unique within a File.
+	Slug string `yaml:"slug"`
+	// SourcePath is the folder, relative to the backend's root, to list
+	// episodes from.
+	SourcePath string `yaml:"source_path"`
+	BaseURL    string `yaml:"base_url"`
+	ImageURL   string `yaml:"image_url"`
+	// ArtworkDir and StatePath default to "artwork/<slug>" and
+	// "dircast-state-<slug>.json" respectively, so podcasts in the same
+	// config file don't collide.
+	ArtworkDir  string `yaml:"artwork_dir,omitempty"`
+	StatePath   string `yaml:"state_path,omitempty"`
+	Title       string `yaml:"title"`
+	Author      string `yaml:"author"`
+	Description string `yaml:"description"`
+	Language    string `yaml:"language,omitempty"`
+	// Category is the podcast's itunes:category text, e.g. "Arts".
+	Category string `yaml:"category,omitempty"`
+	Explicit bool   `yaml:"explicit,omitempty"`
+	// Extensions filters which files are treated as episodes, e.g.
+	// [".mp3", ".m4b"]. Defaults to the tool's built-in audio
+	// extensions when left unset.
+	Extensions []string `yaml:"extensions,omitempty"`
+	// Recursive lists SourcePath's subfolders too, mapping them onto
+	// itunes:season/itunes:episode numbers. See feed.Config.Recursive.
+	Recursive bool `yaml:"recursive,omitempty"`
+}
+
+// Load reads and validates the config file at path, filling in each
+// podcast's ArtworkDir and StatePath defaults from its Slug.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if len(f.Podcasts) == 0 {
+		return nil, fmt.Errorf("config %s declares no podcasts", path)
+	}
+	slugs := make(map[string]bool, len(f.Podcasts))
+	for i, p := range f.Podcasts {
+		if p.Slug == "" {
+			return nil, fmt.Errorf("config %s: podcast %d is missing a slug", path, i)
+		}
+		if slugs[p.Slug] {
+			return nil, fmt.Errorf("config %s: duplicate podcast slug %q", path, p.Slug)
+		}
+		slugs[p.Slug] = true
+		if p.ArtworkDir == "" {
+			p.ArtworkDir = "artwork/" + p.Slug
+		}
+		if p.StatePath == "" {
+			p.StatePath = "dircast-state-" + p.Slug + ".json"
+		}
+		f.Podcasts[i] = p
+	}
+	return &f, nil
+}
+
+// FeedConfig converts p into a feed.Config ready to pass to
+// feed.Generate.
+func (p Podcast) FeedConfig() feed.Config {
+	return feed.Config{
+		SourcePath:  p.SourcePath,
+		BaseURL:     p.BaseURL,
+		ImageURL:    p.ImageURL,
+		ArtworkDir:  p.ArtworkDir,
+		StatePath:   p.StatePath,
+		Title:       p.Title,
+		Author:      p.Author,
+		Description: p.Description,
+		Language:    p.Language,
+		Category:    p.Category,
+		Explicit:    p.Explicit,
+		Extensions:  p.Extensions,
+		Recursive:   p.Recursive,
+		Slug:        p.Slug,
+	}
+}
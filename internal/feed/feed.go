@@ -0,0 +1,427 @@
+// Package feed builds the podcast RSS feed from a backend.Backend,
+// caching what it can in a statecache.Store so repeated calls only do
+// work for files that are new or changed.
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reillywatson/dircast/internal/backend"
+	"github.com/reillywatson/dircast/internal/statecache"
+)
+
+// RSS is the root element of the RSS feed.
+type RSS struct {
+	XMLName  xml.Name `xml:"rss"`
+	Version  string   `xml:"version,attr"`
+	XMLNS    string   `xml:"xmlns:itunes,attr"`
+	PSCXMLNS string   `xml:"xmlns:psc,attr"`
+	Channel  Channel  `xml:"channel"`
+}
+
+// Channel contains information about the podcast channel.
+type Channel struct {
+	Title          string          `xml:"title"`
+	Link           string          `xml:"link"`
+	Description    string          `xml:"description"`
+	Language       string          `xml:"language,omitempty"`
+	ItunesAuthor   string          `xml:"itunes:author"`
+	ItunesImage    ItunesImage     `xml:"itunes:image"`
+	ItunesCategory *ItunesCategory `xml:"itunes:category,omitempty"`
+	ItunesExplicit string          `xml:"itunes:explicit,omitempty"`
+	Items          []Item          `xml:"item"`
+}
+
+// ItunesImage represents the podcast's cover image.
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// ItunesCategory represents the podcast's iTunes category, e.g.
+// <itunes:category text="Arts"/>.
+type ItunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+// Item represents a single episode of the podcast.
+type Item struct {
+	Title          string       `xml:"title"`
+	Link           string       `xml:"link"`
+	GUID           string       `xml:"guid"`
+	PubDate        string       `xml:"pubDate"`
+	Enclosure      Enclosure    `xml:"enclosure"`
+	ItunesAuthor   string       `xml:"itunes:author,omitempty"`
+	ItunesSummary  string       `xml:"itunes:summary,omitempty"`
+	ItunesDuration string       `xml:"itunes:duration,omitempty"`
+	ItunesImage    *ItunesImage `xml:"itunes:image,omitempty"`
+	ItunesSeason   string       `xml:"itunes:season,omitempty"`
+	ItunesEpisode  string       `xml:"itunes:episode,omitempty"`
+	Chapters       *PSCChapters `xml:"psc:chapters"`
+}
+
+// Enclosure represents the media file associated with an item.
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// PSCChapters is a Podlove Simple Chapters block, used to expose chapter
+// markers extracted from audiobook (.m4b) files.
+type PSCChapters struct {
+	Version  string       `xml:"version,attr"`
+	Chapters []PSCChapter `xml:"psc:chapter"`
+}
+
+// PSCChapter is a single chapter within a PSCChapters block.
+type PSCChapter struct {
+	Start string `xml:"start,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// Config describes one podcast to generate a feed for.
+type Config struct {
+	// SourcePath is the folder, relative to the backend's root, to list
+	// episodes from.
+	SourcePath  string
+	BaseURL     string
+	ImageURL    string
+	ArtworkDir  string
+	StatePath   string
+	Title       string
+	Author      string
+	Description string
+	Language    string
+	// Category is the podcast's itunes:category text, e.g. "Arts".
+	Category string
+	Explicit bool
+
+	// Extensions lists the file extensions (with leading dot, e.g.
+	// ".mp3") treated as episode audio. A nil slice falls back to the
+	// tool's built-in default of .mp3/.m4a/.m4b.
+	Extensions []string
+
+	// Recursive lists SourcePath's subfolders too, if the backend
+	// supports it (see backend.RecursiveLister). Episodes found in a
+	// subfolder named like "S02" are tagged itunes:season 2; a leading
+	// number in the file name (e.g. "03 - Title.mp3") becomes
+	// itunes:episode.
+	Recursive bool
+
+	// Slug identifies this podcast among others served by the same
+	// dircast serve process, e.g. "/feeds/{slug}.xml" and
+	// "/audio/{slug}/{fileId}". Left blank when there's only one
+	// podcast being served.
+	Slug string
+
+	// AudioBaseURL, when set, makes episode enclosures point at
+	// AudioBaseURL + "/audio/{fileId}" (or, if Slug is set,
+	// AudioBaseURL + "/audio/{slug}/{fileId}") instead of directly at a
+	// backend's public URL, so server mode can mint a fresh temporary
+	// link on every request rather than embedding a long-lived one in
+	// the feed.
+	AudioBaseURL string
+}
+
+// AudioURL returns the URL the /audio endpoint responds to for the given
+// file, under cfg.AudioBaseURL.
+func (cfg Config) AudioURL(fileID string) string {
+	base := strings.TrimSuffix(cfg.AudioBaseURL, "/")
+	if cfg.Slug != "" {
+		return base + "/audio/" + cfg.Slug + "/" + fileID
+	}
+	return base + "/audio/" + fileID
+}
+
+// Generate lists cfg.SourcePath on b (using and updating the cache at
+// cfg.StatePath), and returns the resulting feed as marshaled XML.
+func Generate(b backend.Backend, cfg Config) ([]byte, error) {
+	store, err := statecache.Load(cfg.StatePath)
+	if err != nil {
+		return nil, fmt.Errorf("loading state cache %s: %w", cfg.StatePath, err)
+	}
+
+	if err := refreshStore(store, b, cfg); err != nil {
+		return nil, fmt.Errorf("listing files: %w", err)
+	}
+	if err := store.Save(cfg.StatePath); err != nil {
+		log.Printf("Failed to save state cache %s: %s", cfg.StatePath, err)
+	}
+
+	channel := Channel{
+		Title:        cfg.Title,
+		Link:         cfg.BaseURL,
+		Description:  cfg.Description,
+		Language:     cfg.Language,
+		ItunesAuthor: cfg.Author,
+		ItunesImage:  ItunesImage{Href: cfg.ImageURL},
+		Items:        buildItems(store, cfg),
+	}
+	if cfg.Category != "" {
+		channel.ItunesCategory = &ItunesCategory{Text: cfg.Category}
+	}
+	if cfg.Explicit {
+		channel.ItunesExplicit = "yes"
+	}
+
+	rss := RSS{
+		Version:  "2.0",
+		XMLNS:    "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		PSCXMLNS: "http://podlove.org/simple-chapters",
+		Channel:  channel,
+	}
+
+	xmlData, err := xml.MarshalIndent(rss, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling XML: %w", err)
+	}
+	return xmlData, nil
+}
+
+// defaultExtensions are the file extensions treated as episode audio
+// when a Config doesn't set its own Extensions filter.
+var defaultExtensions = []string{".mp3", ".m4a", ".m4b"}
+
+// isAudioFile reports whether fileName has one of extensions (or, if
+// extensions is empty, one of defaultExtensions).
+func isAudioFile(fileName string, extensions []string) bool {
+	if len(extensions) == 0 {
+		extensions = defaultExtensions
+	}
+	for _, ext := range extensions {
+		if strings.HasSuffix(fileName, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// seasonFolderRe matches a subfolder name like "S02" or "s2" as a season
+// number. episodeNumberRe matches a file name's leading digits, e.g. the
+// "03" in "03 - Title.mp3", as an episode number.
+var (
+	seasonFolderRe  = regexp.MustCompile(`(?i)^s(\d+)$`)
+	episodeNumberRe = regexp.MustCompile(`^(\d+)`)
+)
+
+// seasonEpisode derives a season and episode number for file from its
+// path relative to sourcePath, e.g. "MyPodcast/S02/03 - Title.mp3"
+// becomes season 2, episode 3. Either or both may come back zero if the
+// subfolder or file name don't match the expected pattern.
+func seasonEpisode(sourcePath string, file backend.Entry) (season, episode int) {
+	dir := path.Dir(file.Path)
+	rel := strings.TrimPrefix(strings.ToLower(dir), strings.ToLower(strings.TrimSuffix(sourcePath, "/")))
+	rel = strings.Trim(rel, "/")
+	if rel != "" {
+		parts := strings.Split(rel, "/")
+		if m := seasonFolderRe.FindStringSubmatch(parts[len(parts)-1]); m != nil {
+			season, _ = strconv.Atoi(m[1])
+		}
+	}
+	if m := episodeNumberRe.FindStringSubmatch(file.Name); m != nil {
+		episode, _ = strconv.Atoi(m[1])
+	}
+	return season, episode
+}
+
+// refreshStore brings store up to date with the current contents of
+// cfg.SourcePath on b. Only files that are new or whose content hash
+// changed are re-processed (public URL + tag extraction); everything
+// else is served straight from the cache. Entries whose files have
+// disappeared from the listing are evicted.
+func refreshStore(store *statecache.Store, b backend.Backend, cfg Config) error {
+	if cfg.Recursive {
+		lister, ok := b.(backend.RecursiveLister)
+		if !ok {
+			return fmt.Errorf("recursive listing requested but this backend doesn't support it")
+		}
+		entries, err := lister.ListRecursive(context.Background(), cfg.SourcePath)
+		if err != nil {
+			return err
+		}
+		return refreshFromListing(store, b, cfg, entries)
+	}
+
+	if cursorLister, ok := b.(backend.CursorLister); ok {
+		entries, cursor, err := cursorLister.ListSince(context.Background(), cfg.SourcePath, store.Cursor)
+		if err != nil {
+			return err
+		}
+		refreshFromDelta(store, b, cfg, entries)
+		store.Cursor = cursor
+		return nil
+	}
+
+	entries, err := b.List(context.Background(), cfg.SourcePath)
+	if err != nil {
+		return err
+	}
+	return refreshFromListing(store, b, cfg, entries)
+}
+
+// refreshFromListing brings store up to date from a full, current
+// listing: files that are new or whose content hash changed are
+// re-processed, and any cached entry not present in entries is evicted.
+func refreshFromListing(store *statecache.Store, b backend.Backend, cfg Config, entries []backend.Entry) error {
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if !isAudioFile(e.Name, cfg.Extensions) {
+			continue
+		}
+		seen[e.ID] = true
+		updateEntry(store, b, cfg, e)
+	}
+	for id := range store.Entries {
+		if !seen[id] {
+			store.Delete(id)
+		}
+	}
+	return nil
+}
+
+// refreshFromDelta applies a CursorLister's incremental result: deleted
+// entries are evicted directly (there's no full listing to diff
+// against), and everything else is processed the same as a full
+// listing's new-or-changed files.
+func refreshFromDelta(store *statecache.Store, b backend.Backend, cfg Config, entries []backend.Entry) {
+	for _, e := range entries {
+		if e.Deleted {
+			store.DeleteByPath(e.Path)
+			continue
+		}
+		if !isAudioFile(e.Name, cfg.Extensions) {
+			continue
+		}
+		updateEntry(store, b, cfg, e)
+	}
+}
+
+// updateEntry refreshes store's cached entry for e: a cache hit just
+// gets its freshness metadata bumped, while a miss pays for processFile.
+func updateEntry(store *statecache.Store, b backend.Backend, cfg Config, e backend.Entry) {
+	if cached, ok := store.Get(e.ID, e.ContentHash); ok {
+		cached.FileID = e.ID
+		cached.ServerModified = e.ModTime.Format(time.RFC3339)
+		store.Set(e.ID, cached)
+		return
+	}
+	cacheEntry, err := processFile(b, e, cfg)
+	if err != nil {
+		log.Printf("Failed to process %s: %s", e.Name, err)
+		return
+	}
+	cacheEntry.FileID = e.ID
+	store.Set(e.ID, cacheEntry)
+}
+
+// processFile does the expensive, one-time work for a new or changed
+// file: resolving its public URL and extracting tag metadata. The
+// result is cached in the state store so future runs can skip straight
+// to it.
+func processFile(b backend.Backend, file backend.Entry, cfg Config) (statecache.Entry, error) {
+	downloadURL, err := b.PublicURL(context.Background(), file.Path)
+	if err != nil {
+		return statecache.Entry{}, fmt.Errorf("getting public URL: %w", err)
+	}
+
+	enclosureType := "audio/x-m4a"
+	if strings.HasSuffix(file.Name, ".mp3") {
+		enclosureType = "audio/mpeg"
+	}
+
+	item := Item{
+		Title:        file.Name,
+		ItunesAuthor: cfg.Author,
+	}
+	populateTagMetadata(&item, b, file.Path, downloadURL, file.Name, cfg.BaseURL, cfg.ArtworkDir)
+
+	entry := statecache.Entry{
+		Path:           file.Path,
+		FileName:       file.Name,
+		ContentHash:    file.ContentHash,
+		Size:           file.Size,
+		ServerModified: file.ModTime.Format(time.RFC3339),
+		DownloadURL:    downloadURL,
+		EnclosureType:  enclosureType,
+		Title:          item.Title,
+		Author:         item.ItunesAuthor,
+		Summary:        item.ItunesSummary,
+		ItunesDuration: item.ItunesDuration,
+	}
+	if cfg.Recursive {
+		entry.Season, entry.Episode = seasonEpisode(cfg.SourcePath, file)
+	}
+	if item.ItunesImage != nil {
+		entry.ImageHref = item.ItunesImage.Href
+	}
+	if item.Chapters != nil {
+		for _, c := range item.Chapters.Chapters {
+			entry.Chapters = append(entry.Chapters, statecache.Chapter{Title: c.Title, Start: c.Start})
+		}
+	}
+	return entry, nil
+}
+
+// buildItems converts every cached entry in store into a feed Item, in a
+// stable order so repeated runs produce a deterministic feed even though
+// map iteration order isn't.
+func buildItems(store *statecache.Store, cfg Config) []Item {
+	entries := make([]statecache.Entry, 0, len(store.Entries))
+	for _, e := range store.Entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].FileName < entries[j].FileName })
+
+	items := make([]Item, 0, len(entries))
+	for _, e := range entries {
+		modified, err := time.Parse(time.RFC3339, e.ServerModified)
+		if err != nil {
+			modified = time.Time{}
+		}
+		enclosureURL := e.DownloadURL
+		if cfg.AudioBaseURL != "" {
+			enclosureURL = cfg.AudioURL(e.FileID)
+		}
+		item := Item{
+			Title:          e.Title,
+			Link:           enclosureURL,
+			GUID:           enclosureURL,
+			PubDate:        modified.Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+			ItunesAuthor:   e.Author,
+			ItunesSummary:  e.Summary,
+			ItunesDuration: e.ItunesDuration,
+			Enclosure: Enclosure{
+				URL:    enclosureURL,
+				Length: int64(e.Size),
+				Type:   e.EnclosureType,
+			},
+		}
+		if e.ImageHref != "" {
+			item.ItunesImage = &ItunesImage{Href: e.ImageHref}
+		}
+		if e.Season > 0 {
+			item.ItunesSeason = strconv.Itoa(e.Season)
+		}
+		if e.Episode > 0 {
+			item.ItunesEpisode = strconv.Itoa(e.Episode)
+		}
+		if len(e.Chapters) > 0 {
+			chapters := &PSCChapters{Version: "1.2"}
+			for _, c := range e.Chapters {
+				chapters.Chapters = append(chapters.Chapters, PSCChapter{Start: c.Start, Title: c.Title})
+			}
+			item.Chapters = chapters
+		}
+		items = append(items, item)
+	}
+	return items
+}
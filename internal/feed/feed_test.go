@@ -0,0 +1,70 @@
+package feed
+
+import (
+	"path"
+	"testing"
+
+	"github.com/reillywatson/dircast/internal/backend"
+)
+
+func TestSeasonEpisode(t *testing.T) {
+	tests := []struct {
+		name        string
+		sourcePath  string
+		path        string
+		wantSeason  int
+		wantEpisode int
+	}{
+		{
+			name:        "season folder and episode number",
+			sourcePath:  "MyPodcast",
+			path:        "MyPodcast/S02/03 - Title.mp3",
+			wantSeason:  2,
+			wantEpisode: 3,
+		},
+		{
+			name:        "lowercase season folder",
+			sourcePath:  "MyPodcast",
+			path:        "MyPodcast/s2/7 - Title.mp3",
+			wantSeason:  2,
+			wantEpisode: 7,
+		},
+		{
+			name:        "no season folder, just episode number",
+			sourcePath:  "MyPodcast",
+			path:        "MyPodcast/03 - Title.mp3",
+			wantSeason:  0,
+			wantEpisode: 3,
+		},
+		{
+			name:        "no leading number in file name",
+			sourcePath:  "MyPodcast",
+			path:        "MyPodcast/S02/Title.mp3",
+			wantSeason:  2,
+			wantEpisode: 0,
+		},
+		{
+			name:        "folder name that isn't a season marker",
+			sourcePath:  "MyPodcast",
+			path:        "MyPodcast/Bonus/03 - Title.mp3",
+			wantSeason:  0,
+			wantEpisode: 3,
+		},
+		{
+			name:        "sourcePath with trailing slash",
+			sourcePath:  "MyPodcast/",
+			path:        "MyPodcast/S02/03 - Title.mp3",
+			wantSeason:  2,
+			wantEpisode: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := backend.Entry{Path: tt.path, Name: path.Base(tt.path)}
+			season, episode := seasonEpisode(tt.sourcePath, entry)
+			if season != tt.wantSeason || episode != tt.wantEpisode {
+				t.Errorf("seasonEpisode(%q, %q) = (%d, %d), want (%d, %d)", tt.sourcePath, tt.path, season, episode, tt.wantSeason, tt.wantEpisode)
+			}
+		})
+	}
+}
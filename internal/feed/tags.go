@@ -0,0 +1,153 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reillywatson/dircast/internal/backend"
+	"github.com/reillywatson/dircast/internal/tagread"
+)
+
+// ffprobeOutput is used to unmarshal the JSON output of ffprobe.
+type ffprobeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// populateTagMetadata fills in the itunes:* fields and chapter list on item
+// by streaming just the tag portions of the file at downloadURL via HTTP
+// Range requests. Cover art, if found, is written under artworkDir so it
+// can be served alongside the generated feed. If the pure-Go parser can't
+// make sense of the file at all, it falls back to downloading the file
+// and shelling out to ffprobe just to get a duration.
+func populateTagMetadata(item *Item, b backend.Backend, filePath, downloadURL, fileName, baseImageURL, artworkDir string) {
+	ctx := context.Background()
+	fetcher := tagread.NewHTTPRangeFetcher(downloadURL, nil)
+	meta, err := tagread.Extract(ctx, fetcher, fileName)
+	if err != nil {
+		log.Printf("Pure-Go tag extraction failed for %s, falling back to ffprobe for duration: %s", fileName, err)
+		if duration, err := getAudioDurationFromBackend(b, filePath); err != nil {
+			log.Printf("ffprobe fallback also failed for %s: %s", fileName, err)
+		} else {
+			item.ItunesDuration = formatDuration(duration)
+		}
+		return
+	}
+
+	if meta.Title != "" {
+		item.Title = meta.Title
+	}
+	if meta.Author != "" {
+		item.ItunesAuthor = meta.Author
+	}
+	item.ItunesSummary = meta.Summary
+	if meta.Duration > 0 {
+		item.ItunesDuration = formatDuration(meta.Duration)
+	} else if duration, err := getAudioDurationFromBackend(b, filePath); err == nil {
+		item.ItunesDuration = formatDuration(duration)
+	}
+
+	if len(meta.ImageData) > 0 {
+		if href, err := saveEpisodeArtwork(artworkDir, baseImageURL, fileName, meta); err != nil {
+			log.Printf("Failed to save episode artwork for %s: %s", fileName, err)
+		} else {
+			item.ItunesImage = &ItunesImage{Href: href}
+		}
+	}
+
+	if len(meta.Chapters) > 0 {
+		chapters := &PSCChapters{Version: "1.2"}
+		for _, c := range meta.Chapters {
+			chapters.Chapters = append(chapters.Chapters, PSCChapter{
+				Start: formatDuration(c.Start),
+				Title: c.Title,
+			})
+		}
+		item.Chapters = chapters
+	}
+}
+
+// saveEpisodeArtwork writes the cover art extracted from a single episode
+// to artworkDir and returns the URL it will be reachable at once artworkDir
+// is served from baseURL.
+func saveEpisodeArtwork(artworkDir, baseURL string, fileName string, meta *tagread.Metadata) (string, error) {
+	if err := os.MkdirAll(artworkDir, 0o755); err != nil {
+		return "", err
+	}
+	ext := ".jpg"
+	if meta.ImageMIME == "image/png" {
+		ext = ".png"
+	}
+	imageName := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ext
+	if err := ioutil.WriteFile(filepath.Join(artworkDir, imageName), meta.ImageData, 0o644); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + path.Join(artworkDir, imageName), nil
+}
+
+// getAudioDurationFromBackend downloads the file at path in full and
+// shells out to ffprobe to measure its duration. This is only used as a
+// fallback when the pure-Go tag parser in internal/tagread can't read a
+// duration out of the file directly.
+func getAudioDurationFromBackend(b backend.Backend, path string) (time.Duration, error) {
+	content, err := b.Open(context.Background(), path, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+
+	tmpfile, err := ioutil.TempFile("", "dircast-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tmpfile.Write(data); err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_format", "-of", "json", tmpfile.Name())
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, err
+	}
+
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(duration * float64(time.Second)), nil
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
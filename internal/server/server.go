@@ -0,0 +1,301 @@
+// Package server runs dircast in long-running HTTP server mode: it
+// regenerates one or more feeds on an interval or Dropbox webhook
+// notification, serves them from memory, and proxies audio enclosures
+// rather than baking a long-lived public URL into the feed.
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reillywatson/dircast/internal/backend"
+	"github.com/reillywatson/dircast/internal/feed"
+	"github.com/reillywatson/dircast/internal/statecache"
+)
+
+// temporaryLinker is implemented by backends (currently only
+// backend.Dropbox) that can mint a short-lived direct link to a file.
+// handleAudio prefers redirecting to one of these over proxying the
+// bytes itself, since it's cheaper for both ends.
+type temporaryLinker interface {
+	TemporaryLink(path string) (string, error)
+}
+
+// Config configures a Server.
+type Config struct {
+	// Addr is the address to listen on, e.g. ":8080".
+	Addr string
+	// Interval is how often to regenerate every feed in the background.
+	// Zero disables the timer; feeds are still regenerated on first
+	// request and whenever /webhook fires.
+	Interval time.Duration
+	// WebhookSecret verifies the X-Dropbox-Signature header on
+	// /webhook notifications. If empty, signature verification is
+	// skipped (not recommended outside local testing).
+	WebhookSecret string
+	// Feeds is the podcast(s) to generate. A single entry with a blank
+	// Slug is served at /feed.xml; otherwise each is served at
+	// /feeds/{slug}.xml. Every entry's AudioBaseURL is set automatically
+	// from its own BaseURL if left blank.
+	Feeds []feed.Config
+	// Backend is the storage backend episodes are read from, shared by
+	// every feed in Feeds.
+	Backend backend.Backend
+}
+
+// feedState is one feed's generated XML and the cache it was built
+// from, refreshed independently of every other feed being served.
+type feedState struct {
+	xmlData      []byte
+	etag         string
+	lastModified time.Time
+	store        *statecache.Store
+}
+
+// Server serves the feeds in its Config, regenerating them in the
+// background and proxying audio enclosures through the backend,
+// preferring a temporary link when the backend supports minting one.
+type Server struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	states map[string]*feedState // keyed by feed.Config.Slug
+}
+
+// New returns a Server for cfg. Call ListenAndServe to start it.
+func New(cfg Config) *Server {
+	for i, f := range cfg.Feeds {
+		if f.AudioBaseURL == "" {
+			f.AudioBaseURL = f.BaseURL
+		}
+		cfg.Feeds[i] = f
+	}
+	return &Server{cfg: cfg, states: map[string]*feedState{}}
+}
+
+// ListenAndServe generates every feed once, starts the background
+// regeneration timer (if configured), and serves HTTP until an
+// unrecoverable error occurs.
+func (s *Server) ListenAndServe() error {
+	if err := s.regenerateAll(); err != nil {
+		return fmt.Errorf("generating initial feed: %w", err)
+	}
+
+	if s.cfg.Interval > 0 {
+		go s.regenerateLoop()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/audio/", s.handleAudio)
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	if len(s.cfg.Feeds) == 1 && s.cfg.Feeds[0].Slug == "" {
+		mux.HandleFunc("/feed.xml", s.handleFeed(""))
+	} else {
+		for _, f := range s.cfg.Feeds {
+			mux.HandleFunc("/feeds/"+f.Slug+".xml", s.handleFeed(f.Slug))
+		}
+	}
+	return http.ListenAndServe(s.cfg.Addr, mux)
+}
+
+func (s *Server) regenerateLoop() {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.regenerateAll(); err != nil {
+			log.Printf("Failed to regenerate feeds: %s", err)
+		}
+	}
+}
+
+// regenerateAll rebuilds every feed in s.cfg.Feeds. It keeps going after
+// an individual feed fails, so one broken podcast doesn't take the rest
+// down with it, and only returns an error if every feed failed.
+func (s *Server) regenerateAll() error {
+	failures := 0
+	for _, f := range s.cfg.Feeds {
+		if err := s.regenerate(f); err != nil {
+			log.Printf("Failed to regenerate feed %q: %s", f.Slug, err)
+			failures++
+		}
+	}
+	if failures == len(s.cfg.Feeds) {
+		return fmt.Errorf("all %d feed(s) failed to regenerate", failures)
+	}
+	return nil
+}
+
+// regenerate rebuilds a single feed and reloads the state cache it was
+// built from, so /audio/{fileId} can resolve file IDs to backend paths.
+func (s *Server) regenerate(f feed.Config) error {
+	xmlData, err := feed.Generate(s.cfg.Backend, f)
+	if err != nil {
+		return err
+	}
+	store, err := statecache.Load(f.StatePath)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.states[f.Slug] = &feedState{
+		xmlData:      xmlData,
+		store:        store,
+		etag:         fmt.Sprintf(`"%x"`, sha256.Sum256(xmlData)),
+		lastModified: time.Now().UTC(),
+	}
+	s.mu.Unlock()
+
+	log.Printf("Regenerated feed %q (%d bytes, %d episodes)", f.Slug, len(xmlData), len(store.Entries))
+	return nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleFeed returns a handler that serves the feed identified by slug.
+func (s *Server) handleFeed(slug string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		state := s.states[slug]
+		s.mu.RUnlock()
+		if state == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("ETag", state.etag)
+		w.Header().Set("Last-Modified", state.lastModified.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+
+		if match := r.Header.Get("If-None-Match"); match != "" && match == state.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if since := r.Header.Get("If-Modified-Since"); since != "" {
+			if t, err := http.ParseTime(since); err == nil && !state.lastModified.After(t) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write(state.xmlData)
+	}
+}
+
+// handleAudio resolves /audio/{fileId} (single-podcast mode) or
+// /audio/{slug}/{fileId} (multi-podcast mode) to a backend path via the
+// matching feed's state cache. If the backend supports minting a
+// temporary link, it 302-redirects to one so the feed never needs to
+// embed a long-lived public URL and clients always get a working URL
+// regardless of when they request it. Otherwise it proxies the file's
+// bytes directly, forwarding any Range header so scrubbing and resuming
+// still work.
+func (s *Server) handleAudio(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/audio/")
+	if rest == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var slug, fileID string
+	if len(s.cfg.Feeds) == 1 && s.cfg.Feeds[0].Slug == "" {
+		fileID = rest
+	} else if parts := strings.SplitN(rest, "/", 2); len(parts) == 2 {
+		slug, fileID = parts[0], parts[1]
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	state := s.states[slug]
+	s.mu.RUnlock()
+	if state == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	entry, ok := state.store.Entries[fileID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if linker, ok := s.cfg.Backend.(temporaryLinker); ok {
+		link, err := linker.TemporaryLink(entry.Path)
+		if err != nil {
+			log.Printf("Failed to mint temporary link for %s: %s", entry.FileName, err)
+			http.Error(w, "failed to mint temporary link", http.StatusBadGateway)
+			return
+		}
+		http.Redirect(w, r, link, http.StatusFound)
+		return
+	}
+
+	var offset int64
+	if rng := r.Header.Get("Range"); rng != "" {
+		fmt.Sscanf(rng, "bytes=%d-", &offset)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, int64(entry.Size)-1, entry.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	w.Header().Set("Content-Type", entry.EnclosureType)
+	content, err := s.cfg.Backend.Open(r.Context(), entry.Path, offset)
+	if err != nil {
+		log.Printf("Failed to open %s: %s", entry.FileName, err)
+		http.Error(w, "failed to open file", http.StatusBadGateway)
+		return
+	}
+	defer content.Close()
+	io.Copy(w, content)
+}
+
+// handleWebhook implements the Dropbox webhook handshake (GET with a
+// challenge query parameter, echoed back verbatim) and notification
+// delivery (POST, HMAC-SHA256 signed), triggering a regeneration of
+// every feed whenever a change notification arrives.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		challenge := r.URL.Query().Get("challenge")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		fmt.Fprint(w, challenge)
+	case http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if s.cfg.WebhookSecret != "" && !validSignature(s.cfg.WebhookSecret, body, r.Header.Get("X-Dropbox-Signature")) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		go func() {
+			if err := s.regenerateAll(); err != nil {
+				log.Printf("Failed to regenerate feeds after webhook: %s", err)
+			}
+		}()
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func validSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := fmt.Sprintf("%x", mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,114 @@
+// Package statecache persists what dircast already knows about each file
+// in the watched folder -- its public URL, size, and tag-derived
+// metadata -- so that repeated runs only have to do work for files that
+// are new or have changed, instead of re-processing the whole folder
+// every time.
+package statecache
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Entry is everything about one file that's expensive to recompute:
+// its public URL, size/mtime used to detect staleness, and the tags
+// extracted by internal/tagread.
+type Entry struct {
+	FileID         string `json:"file_id"`
+	Path           string `json:"path"`
+	FileName       string `json:"file_name"`
+	ContentHash    string `json:"content_hash"`
+	Size           uint64 `json:"size"`
+	ServerModified string `json:"server_modified"`
+	DownloadURL    string `json:"download_url"`
+	EnclosureType  string `json:"enclosure_type"`
+
+	Title          string    `json:"title,omitempty"`
+	Author         string    `json:"author,omitempty"`
+	Summary        string    `json:"summary,omitempty"`
+	ItunesDuration string    `json:"itunes_duration,omitempty"`
+	ImageHref      string    `json:"image_href,omitempty"`
+	Chapters       []Chapter `json:"chapters,omitempty"`
+
+	// Season and Episode come from the file's subfolder/name when the
+	// podcast is configured for recursive listing; zero means unknown.
+	Season  int `json:"season,omitempty"`
+	Episode int `json:"episode,omitempty"`
+}
+
+// Chapter mirrors tagread.Chapter in a JSON-friendly form.
+type Chapter struct {
+	Title string `json:"title"`
+	Start string `json:"start"`
+}
+
+// Store is the on-disk cache, keyed by backend file ID.
+type Store struct {
+	// Entries maps a backend.Entry's ID to what we know about that file.
+	Entries map[string]Entry `json:"entries"`
+	// Cursor is an opaque position from a backend.CursorLister, letting
+	// the next run resume from it instead of re-listing everything.
+	// Left blank for backends that don't support cursor-based listing.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// Load reads the state file at path. A missing file is not an error --
+// it just means this is the first run, so an empty Store is returned.
+func Load(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Store{Entries: map[string]Entry{}}, nil
+		}
+		return nil, err
+	}
+	var s Store
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Entries == nil {
+		s.Entries = map[string]Entry{}
+	}
+	return &s, nil
+}
+
+// Save writes the store to path as indented JSON.
+func (s *Store) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Get returns the cached entry for fileID, and whether it's still valid
+// for the given contentHash.
+func (s *Store) Get(fileID, contentHash string) (Entry, bool) {
+	entry, ok := s.Entries[fileID]
+	if !ok || entry.ContentHash != contentHash {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Set records or replaces the cached entry for fileID.
+func (s *Store) Set(fileID string, entry Entry) {
+	s.Entries[fileID] = entry
+}
+
+// Delete removes any cached entry for fileID.
+func (s *Store) Delete(fileID string) {
+	delete(s.Entries, fileID)
+}
+
+// DeleteByPath removes the cached entry whose Path matches path, if any.
+// Used to evict entries for files that disappeared from a listing
+// without knowing their backend ID up front.
+func (s *Store) DeleteByPath(path string) {
+	for id, entry := range s.Entries {
+		if entry.Path == path {
+			delete(s.Entries, id)
+			return
+		}
+	}
+}
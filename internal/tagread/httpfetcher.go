@@ -0,0 +1,79 @@
+package tagread
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPRangeFetcher implements RangeFetcher against a plain HTTP(S) URL
+// using Range requests, so callers (e.g. a Dropbox direct-download link)
+// never need to pull down the whole file just to read its tags.
+type HTTPRangeFetcher struct {
+	URL    string
+	Client *http.Client
+
+	size int64
+}
+
+// NewHTTPRangeFetcher returns a fetcher for url. If client is nil,
+// http.DefaultClient is used.
+func NewHTTPRangeFetcher(url string, client *http.Client) *HTTPRangeFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPRangeFetcher{URL: url, Client: client, size: -1}
+}
+
+// FetchRange implements RangeFetcher.
+func (f *HTTPRangeFetcher) FetchRange(ctx context.Context, offset, length int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return io.ReadAll(io.LimitReader(resp.Body, length))
+	case http.StatusOK:
+		// The server ignored our Range header and sent the whole file
+		// from byte 0, so skip to offset ourselves before reading, or
+		// the bytes we hand back would silently be the wrong ones.
+		if offset > 0 {
+			if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				return nil, fmt.Errorf("tagread: skipping to offset %d in unranged response: %w", offset, err)
+			}
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, length))
+	default:
+		return nil, fmt.Errorf("tagread: unexpected status %s fetching range", resp.Status)
+	}
+}
+
+// Size implements RangeFetcher, issuing a HEAD request and caching the
+// result for subsequent calls.
+func (f *HTTPRangeFetcher) Size(ctx context.Context) (int64, error) {
+	if f.size >= 0 {
+		return f.size, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, f.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("tagread: server did not report Content-Length for %s", f.URL)
+	}
+	f.size = resp.ContentLength
+	return f.size, nil
+}
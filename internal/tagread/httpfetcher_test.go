@@ -0,0 +1,74 @@
+package tagread
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchRange(t *testing.T) {
+	const body = "0123456789abcdef"
+
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter, r *http.Request)
+		offset  int64
+		length  int64
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "server honors Range with 206",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes 4-9/16")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(body[4:10]))
+			},
+			offset: 4,
+			length: 6,
+			want:   body[4:10],
+		},
+		{
+			name: "server ignores Range and returns 200 with the whole file",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(body))
+			},
+			offset: 4,
+			length: 6,
+			want:   body[4:10],
+		},
+		{
+			name: "unexpected status is an error",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			offset:  0,
+			length:  4,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(tt.respond))
+			defer srv.Close()
+
+			f := NewHTTPRangeFetcher(srv.URL, nil)
+			got, err := f.FetchRange(context.Background(), tt.offset, tt.length)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FetchRange() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchRange() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("FetchRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+// Package tagread extracts podcast-relevant metadata (title, author,
+// summary, duration, cover art and chapter markers) from MP3 and
+// MP4/M4A/M4B audio files without downloading the whole file. It reads
+// only the byte ranges it needs via the RangeFetcher interface, which
+// callers typically back with HTTP Range requests against a Dropbox
+// shared link.
+package tagread
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapter is a single chapter marker extracted from an M4B audiobook's
+// chapter track.
+type Chapter struct {
+	Title string
+	Start time.Duration
+}
+
+// Metadata is the set of tags this package is able to recover from a
+// file. Any field may be left at its zero value if the source file
+// doesn't carry that tag.
+type Metadata struct {
+	Title     string
+	Author    string
+	Summary   string
+	Duration  time.Duration
+	ImageData []byte
+	ImageMIME string
+	Chapters  []Chapter
+}
+
+// RangeFetcher fetches a byte range of a remote file. Implementations
+// are expected to issue HTTP Range requests rather than downloading the
+// whole file.
+type RangeFetcher interface {
+	// FetchRange returns up to length bytes starting at offset. It may
+	// return fewer bytes than requested if the file is shorter than
+	// offset+length.
+	FetchRange(ctx context.Context, offset, length int64) ([]byte, error)
+	// Size returns the total size of the file in bytes.
+	Size(ctx context.Context) (int64, error)
+}
+
+// initialChunk is how much of the file we read up front to look for an
+// ID3v2 header or the start of the MP4 atom tree. Most tag data lives in
+// the first few KB, but cover art can push it further, so we grow the
+// read if a tag claims to be bigger than what we fetched.
+const initialChunk = 64 * 1024
+
+// Extract parses tags out of the audio file reachable through fetcher.
+// filename is used only to decide which parser to use, based on its
+// extension.
+func Extract(ctx context.Context, fetcher RangeFetcher, filename string) (*Metadata, error) {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".mp3"):
+		return extractID3(ctx, fetcher)
+	case strings.HasSuffix(lower, ".m4a"), strings.HasSuffix(lower, ".m4b"):
+		return extractMP4(ctx, fetcher)
+	default:
+		return nil, fmt.Errorf("tagread: unsupported file extension for %q", filename)
+	}
+}
+
+// --- ID3v2 (MP3) ---
+
+func extractID3(ctx context.Context, fetcher RangeFetcher) (*Metadata, error) {
+	header, err := fetcher.FetchRange(ctx, 0, 10)
+	if err != nil {
+		return nil, fmt.Errorf("tagread: fetching ID3 header: %w", err)
+	}
+	if len(header) < 10 || string(header[0:3]) != "ID3" {
+		return nil, fmt.Errorf("tagread: no ID3v2 header found")
+	}
+	majorVersion := header[3]
+	size := synchsafeToInt(header[6:10])
+
+	body, err := fetcher.FetchRange(ctx, 10, int64(size))
+	if err != nil {
+		return nil, fmt.Errorf("tagread: fetching ID3 tag body: %w", err)
+	}
+
+	meta := &Metadata{}
+	r := bytes.NewReader(body)
+	for r.Len() > 10 {
+		id, frameSize, frameBody, ok := readID3Frame(r, majorVersion)
+		if !ok {
+			break
+		}
+		switch id {
+		case "TIT2":
+			meta.Title = decodeID3Text(frameBody)
+		case "TPE1":
+			meta.Author = decodeID3Text(frameBody)
+		case "COMM":
+			meta.Summary = decodeID3Comment(frameBody)
+		case "APIC":
+			mime, data := decodeID3Picture(frameBody)
+			meta.ImageMIME, meta.ImageData = mime, data
+		case "TLEN":
+			if ms, err := strconv.Atoi(strings.TrimSpace(decodeID3Text(frameBody))); err == nil {
+				meta.Duration = time.Duration(ms) * time.Millisecond
+			}
+		}
+		_ = frameSize
+	}
+	return meta, nil
+}
+
+// readID3Frame reads one ID3v2.3/2.4 frame from r, returning its 4-byte
+// id, declared size, and raw body. ok is false once padding or malformed
+// data is reached.
+func readID3Frame(r *bytes.Reader, majorVersion byte) (id string, size int, body []byte, ok bool) {
+	idBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, idBytes); err != nil {
+		return "", 0, nil, false
+	}
+	if idBytes[0] == 0 {
+		return "", 0, nil, false
+	}
+	sizeBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, sizeBytes); err != nil {
+		return "", 0, nil, false
+	}
+	if majorVersion >= 4 {
+		size = synchsafeToInt(sizeBytes)
+	} else {
+		size = int(binary.BigEndian.Uint32(sizeBytes))
+	}
+	if _, err := io.ReadFull(r, make([]byte, 2)); err != nil { // flags
+		return "", 0, nil, false
+	}
+	if size <= 0 || size > r.Len() {
+		return "", 0, nil, false
+	}
+	body = make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", 0, nil, false
+	}
+	return string(idBytes), size, body, true
+}
+
+func synchsafeToInt(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// decodeID3Text strips the leading text-encoding byte and decodes a
+// text frame, assuming Latin-1 or UTF-8 (encodings 0/3); UTF-16 frames
+// are decoded best-effort by dropping null bytes.
+func decodeID3Text(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	encoding, text := body[0], body[1:]
+	switch encoding {
+	case 1, 2: // UTF-16 (with or without BOM) -- decode best-effort by dropping null bytes
+		return strings.TrimRight(string(bytes.ReplaceAll(text, []byte{0}, nil)), "\ufeff")
+	default: // Latin-1 / UTF-8
+		return strings.TrimRight(string(text), "\x00")
+	}
+}
+
+func decodeID3Comment(body []byte) string {
+	if len(body) < 4 {
+		return ""
+	}
+	// encoding(1) + language(3) + short description + 0x00 + actual text
+	rest := body[4:]
+	if idx := bytes.IndexByte(rest, 0); idx >= 0 {
+		rest = rest[idx+1:]
+	}
+	return decodeID3Text(append([]byte{body[0]}, rest...))
+}
+
+func decodeID3Picture(body []byte) (mime string, data []byte) {
+	if len(body) < 2 {
+		return "", nil
+	}
+	rest := body[1:]
+	idx := bytes.IndexByte(rest, 0)
+	if idx < 0 {
+		return "", nil
+	}
+	mime = string(rest[:idx])
+	rest = rest[idx+1:]
+	if len(rest) < 2 {
+		return mime, nil
+	}
+	rest = rest[1:] // picture type byte
+	idx = bytes.IndexByte(rest, 0)
+	if idx < 0 {
+		return mime, nil
+	}
+	return mime, rest[idx+1:]
+}
+
+// --- MP4 / M4A / M4B ---
+
+// extractMP4 walks the ISO-BMFF atom tree to find the moov/udta/meta/ilst
+// tags (title, artist, description, cover art) and, for chapterized
+// audiobooks, the chpl atom used by Nero/QuickTime-style chapter tracks.
+func extractMP4(ctx context.Context, fetcher RangeFetcher) (*Metadata, error) {
+	size, err := fetcher.Size(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tagread: getting file size: %w", err)
+	}
+
+	moovData, moovOffset, err := findTopLevelAtom(ctx, fetcher, size, "moov")
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{}
+	walkAtoms(moovData, moovOffset, func(path []string, offset int64, payload []byte) {
+		switch strings.Join(path, "/") {
+		case "udta/meta/ilst/\xa9nam/data":
+			meta.Title = decodeMP4DataString(payload)
+		case "udta/meta/ilst/\xa9ART/data":
+			meta.Author = decodeMP4DataString(payload)
+		case "udta/meta/ilst/desc/data", "udta/meta/ilst/\xa9des/data":
+			meta.Summary = decodeMP4DataString(payload)
+		case "udta/meta/ilst/covr/data":
+			meta.ImageData = payload
+			meta.ImageMIME = "image/jpeg"
+			if len(payload) > 4 && payload[0] == 0x89 && payload[1] == 'P' {
+				meta.ImageMIME = "image/png"
+			}
+		case "udta/chpl":
+			meta.Chapters = decodeChpl(payload)
+		}
+	})
+	if mvhdDur, ok := findMvhdDuration(moovData); ok {
+		meta.Duration = mvhdDur
+	}
+	return meta, nil
+}
+
+// findTopLevelAtom scans the top level of the file for an atom with the
+// given fourcc, growing the fetched window until it has the atom's full
+// payload.
+func findTopLevelAtom(ctx context.Context, fetcher RangeFetcher, fileSize int64, fourcc string) (payload []byte, offset int64, err error) {
+	var pos int64
+	chunk := int64(initialChunk)
+	for pos < fileSize {
+		header, err := fetcher.FetchRange(ctx, pos, 8)
+		if err != nil {
+			return nil, 0, fmt.Errorf("tagread: fetching atom header at %d: %w", pos, err)
+		}
+		if len(header) < 8 {
+			break
+		}
+		atomSize := int64(binary.BigEndian.Uint32(header[0:4]))
+		name := string(header[4:8])
+		if atomSize < 8 {
+			break // malformed or 64-bit size, not handled
+		}
+		if name == fourcc {
+			for {
+				data, err := fetcher.FetchRange(ctx, pos+8, atomSize-8)
+				if err != nil {
+					return nil, 0, fmt.Errorf("tagread: fetching %s atom body: %w", fourcc, err)
+				}
+				if int64(len(data)) >= atomSize-8 || int64(len(data)) >= chunk {
+					return data, pos + 8, nil
+				}
+				chunk *= 2
+			}
+		}
+		pos += atomSize
+	}
+	return nil, 0, fmt.Errorf("tagread: %s atom not found", fourcc)
+}
+
+// walkAtoms recursively descends into the container atoms that matter
+// for iTunes-style metadata (moov/udta/meta/ilst/<tag>/data and
+// moov/udta/chpl), calling fn for every leaf it recognizes.
+func walkAtoms(data []byte, baseOffset int64, fn func(path []string, offset int64, payload []byte)) {
+	var walk func(data []byte, offset int64, path []string)
+	containers := map[string]bool{"udta": true, "meta": true, "ilst": true}
+	tags := map[string]bool{"\xa9nam": true, "\xa9ART": true, "desc": true, "\xa9des": true, "covr": true}
+
+	walk = func(data []byte, offset int64, path []string) {
+		pos := 0
+		// the "meta" atom has a 4-byte version/flags field before its children
+		if len(path) > 0 && path[len(path)-1] == "meta" && len(data) >= 4 {
+			pos = 4
+		}
+		for pos+8 <= len(data) {
+			atomSize := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+			name := string(data[pos+4 : pos+8])
+			if atomSize < 8 || pos+atomSize > len(data) {
+				break
+			}
+			body := data[pos+8 : pos+atomSize]
+			childPath := append(append([]string{}, path...), name)
+			switch {
+			case containers[name]:
+				walk(body, offset+int64(pos)+8, childPath)
+			case tags[name]:
+				walk(body, offset+int64(pos)+8, childPath)
+			case name == "data":
+				fn(childPath, offset+int64(pos)+8, body)
+			case name == "chpl":
+				fn(childPath, offset+int64(pos)+8, body)
+			}
+			pos += atomSize
+		}
+	}
+	walk(data, baseOffset, nil)
+}
+
+// decodeMP4DataString strips the 8-byte type+locale header of an ilst
+// "data" atom and returns the remaining UTF-8 text.
+func decodeMP4DataString(payload []byte) string {
+	if len(payload) <= 8 {
+		return ""
+	}
+	return string(payload[8:])
+}
+
+// decodeChpl decodes a Nero-style chpl chapter list atom: a version byte,
+// 3 reserved bytes, a chapter count, then for each chapter an 8-byte
+// 100ns-tick timestamp and a length-prefixed title.
+func decodeChpl(payload []byte) []Chapter {
+	if len(payload) < 5 {
+		return nil
+	}
+	count := int(payload[4])
+	pos := 5
+	chapters := make([]Chapter, 0, count)
+	for i := 0; i < count && pos+9 <= len(payload); i++ {
+		ticks := binary.BigEndian.Uint64(payload[pos : pos+8])
+		titleLen := int(payload[pos+8])
+		pos += 9
+		if pos+titleLen > len(payload) {
+			break
+		}
+		title := string(payload[pos : pos+titleLen])
+		pos += titleLen
+		chapters = append(chapters, Chapter{
+			Title: title,
+			Start: time.Duration(ticks*100) * time.Nanosecond,
+		})
+	}
+	return chapters
+}
+
+// findMvhdDuration locates the movie header atom inside moov and returns
+// the overall track duration.
+func findMvhdDuration(moov []byte) (time.Duration, bool) {
+	pos := 0
+	for pos+8 <= len(moov) {
+		atomSize := int(binary.BigEndian.Uint32(moov[pos : pos+4]))
+		name := string(moov[pos+4 : pos+8])
+		if atomSize < 8 || pos+atomSize > len(moov) {
+			break
+		}
+		if name == "mvhd" {
+			body := moov[pos+8 : pos+atomSize]
+			if len(body) < 1 {
+				return 0, false
+			}
+			version := body[0]
+			var timescale, duration uint64
+			if version == 1 {
+				if len(body) < 28 {
+					return 0, false
+				}
+				timescale = uint64(binary.BigEndian.Uint32(body[20:24]))
+				duration = binary.BigEndian.Uint64(body[24:32])
+			} else {
+				if len(body) < 20 {
+					return 0, false
+				}
+				timescale = uint64(binary.BigEndian.Uint32(body[12:16]))
+				duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+			}
+			if timescale == 0 {
+				return 0, false
+			}
+			return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), true
+		}
+		pos += atomSize
+	}
+	return 0, false
+}
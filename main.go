@@ -1,255 +1,310 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"encoding/xml"
+	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
-	"strconv"
+	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/sharing"
+	"github.com/reillywatson/dircast/internal/backend"
+	"github.com/reillywatson/dircast/internal/config"
+	"github.com/reillywatson/dircast/internal/feed"
+	"github.com/reillywatson/dircast/internal/server"
 )
 
-// RSS is the root element of the RSS feed
-type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Version string   `xml:"version,attr"`
-	XMLNS   string   `xml:"xmlns:itunes,attr"`
-	Channel Channel  `xml:"channel"`
-}
-
-// Channel contains information about the podcast channel
-type Channel struct {
-	Title        string      `xml:"title"`
-	Link         string      `xml:"link"`
-	Description  string      `xml:"description"`
-	ItunesAuthor string      `xml:"itunes:author"`
-	ItunesImage  ItunesImage `xml:"itunes:image"`
-	Items        []Item      `xml:"item"`
-}
-
-// ItunesImage represents the podcast's cover image
-type ItunesImage struct {
-	Href string `xml:"href,attr"`
-}
-
-// Item represents a single episode of the podcast
-type Item struct {
-	Title     string    `xml:"title"`
-	Link      string    `xml:"link"`
-	GUID      string    `xml:"guid"`
-	PubDate   string    `xml:"pubDate"`
-	Enclosure Enclosure `xml:"enclosure"`
-	//ItunesDuration string    `xml:"itunes:duration"`
-}
-
-// Enclosure represents the media file associated with an item
-type Enclosure struct {
-	URL    string `xml:"url,attr"`
-	Length int64  `xml:"length,attr"`
-	Type   string `xml:"type,attr"`
-}
-
-// FFProbeOutput is used to unmarshal the JSON output of ffprobe
-type FFProbeOutput struct {
-	Format struct {
-		Duration string `json:"duration"`
-	} `json:"format"`
-}
-
 func main() {
-	//if _, err := exec.LookPath("ffprobe"); err != nil {
-	//	log.Fatal("ffprobe not found in PATH. Please install ffmpeg.")
-	//}
-
-	if len(os.Args) < 4 { // dropboxPath, baseURL, imageURL
-		fmt.Println("Usage: go run main.go <dropboxPath> <baseURL> <imageURL>")
-		fmt.Println("Refresh token must be set via environment variable DROPBOX_REFRESH_TOKEN or will prompt with interactive flow if unset.")
-		os.Exit(1)
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
 	}
-	dropboxPath := os.Args[1]
-	baseURL := os.Args[2]
-	imageURL := os.Args[3]
+	runOnce(os.Args[1:])
+}
 
-	refreshToken := os.Getenv("DROPBOX_REFRESH_TOKEN")
-	if refreshToken == "" || refreshToken == "-" {
+// runOnce generates the feed(s) once. With a single-podcast shorthand
+// command line it prints the feed to stdout, the historical behavior of
+// this tool; with -config it writes one <outDir>/<slug>.xml per podcast
+// instead.
+func runOnce(args []string) {
+	fs := flag.NewFlagSet("dircast", flag.ExitOnError)
+	artworkDir := fs.String("artwork-dir", "artwork", "directory to write per-episode cover art extracted from file tags; served from <baseURL>/<artwork-dir>/")
+	statePath := fs.String("state", "dircast-state.json", "path to the state cache file used to avoid re-processing unchanged files on subsequent runs")
+	recursive := fs.Bool("recursive", false, "list sourcePath's subfolders too, mapping them onto itunes:season/itunes:episode numbers")
+	configPath := fs.String("config", "", "path to a YAML config declaring one or more podcasts, instead of the <sourcePath> <baseURL> <imageURL> shorthand")
+	outDir := fs.String("out-dir", "", "with -config, directory to write each podcast's <slug>.xml feed to (defaults to the config's own out_dir, or the current directory)")
+	bf := registerBackendFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dircast [flags] <sourcePath> <baseURL> <imageURL>")
+		fmt.Fprintln(os.Stderr, "       dircast [flags] -config <path>")
+		fmt.Fprintln(os.Stderr, "       dircast serve [flags] <sourcePath> <baseURL> <imageURL>")
+		fmt.Fprintln(os.Stderr, "       dircast serve [flags] -config <path>")
+		fmt.Fprintln(os.Stderr, "Refresh token must be set via environment variable DROPBOX_REFRESH_TOKEN or will prompt with interactive flow if unset.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var cfgFile *config.File
+	if *configPath != "" {
 		var err error
-		refreshToken, err = interactiveAuthFlow()
+		cfgFile, err = config.Load(*configPath)
 		if err != nil {
-			log.Fatalf("OAuth flow failed: %v", err)
+			log.Fatalf("Failed to load config: %s", err)
 		}
-		log.Printf("Obtained refresh token. Store this securely and set DROPBOX_REFRESH_TOKEN to avoid interactive prompts: %s", refreshToken)
 	}
+	applyConfigBackend(fs, bf, cfgFile)
 
-	// Remove trailing slash from dropbox path
-	dropboxPath = strings.TrimSuffix(dropboxPath, "/")
-
-	// Exchange refresh token for short-lived access token
-	accessToken, err := fetchAccessToken(refreshToken)
+	b, err := bf.build()
 	if err != nil {
-		log.Fatalf("Failed to obtain access token: %v", err)
+		log.Fatalf("Failed to set up backend: %s", err)
 	}
 
-	config := dropbox.Config{Token: accessToken}
-	dbxf := files.New(config)
-	dbxs := sharing.New(config)
-
-	listFolderArg := files.NewListFolderArg(dropboxPath)
-	listFolderResult, err := dbxf.ListFolder(listFolderArg)
-	if err != nil {
-		log.Fatalf("Failed to list files in Dropbox: %s", err)
-	}
-
-	var items []Item
-	for _, entry := range listFolderResult.Entries {
-		if file, ok := entry.(*files.FileMetadata); ok {
-			fileName := file.Name
-			if strings.HasSuffix(fileName, ".mp3") || strings.HasSuffix(fileName, ".m4a") || strings.HasSuffix(fileName, ".m4b") {
-				sharedLink, err := getOrCreateSharedLink(dbxs, file.PathLower)
-				if err != nil {
-					log.Printf("Failed to create or get shared link for %s: %s", fileName, err)
-					continue
-				}
-
-				downloadURL := strings.Replace(sharedLink, "www.dropbox.com", "dl.dropboxusercontent.com", 1)
-				downloadURL = strings.Replace(downloadURL, "dl=0", "dl=1", 1)
-
-				/*
-					duration, err := getAudioDurationFromDropbox(dbxf, file.PathLower)
-					if err != nil {
-						log.Printf("Failed to get duration for %s: %s", fileName, err)
-					}*/
-
-				enclosureType := ""
-				if strings.HasSuffix(fileName, ".mp3") {
-					enclosureType = "audio/mpeg"
-				} else {
-					enclosureType = "audio/x-m4a"
-				}
-
-				item := Item{
-					Title:   fileName,
-					Link:    downloadURL,
-					GUID:    downloadURL,
-					PubDate: file.ServerModified.Format("Mon, 02 Jan 2006 15:04:05 -0700"),
-					Enclosure: Enclosure{
-						URL:    downloadURL,
-						Length: int64(file.Size),
-						Type:   enclosureType,
-					},
-					//ItunesDuration: formatDuration(duration),
-				}
-				items = append(items, item)
-			}
-		}
+	if cfgFile != nil {
+		runOnceConfig(cfgFile, *outDir, b)
+		return
 	}
 
-	rss := RSS{
-		Version: "2.0",
-		XMLNS:   "http://www.itunes.com/dtds/podcast-1.0.dtd",
-		Channel: Channel{
-			Title:        "Reilly's Awesome Podcast",
-			Link:         baseURL,
-			Description:  "It's Reilly's Podcast, Baby!",
-			ItunesAuthor: "Reilly Watson",
-			ItunesImage: ItunesImage{
-				Href: imageURL,
-			},
-			Items: items,
-		},
+	if fs.NArg() < 3 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	cfg := feed.Config{
+		SourcePath:  strings.TrimSuffix(fs.Arg(0), "/"),
+		BaseURL:     fs.Arg(1),
+		ImageURL:    fs.Arg(2),
+		ArtworkDir:  *artworkDir,
+		StatePath:   *statePath,
+		Recursive:   *recursive,
+		Title:       "Reilly's Awesome Podcast",
+		Author:      "Reilly Watson",
+		Description: "It's Reilly's Podcast, Baby!",
 	}
 
-	xmlData, err := xml.MarshalIndent(rss, "", "  ")
+	xmlData, err := feed.Generate(b, cfg)
 	if err != nil {
-		log.Fatalf("Failed to marshal XML: %s", err)
+		log.Fatalf("Failed to generate feed: %s", err)
 	}
-
 	fmt.Println(string(xmlData))
 }
 
-func getOrCreateSharedLink(dbxs sharing.Client, path string) (string, error) {
-	arg := sharing.NewCreateSharedLinkArg(path)
-	link, err := dbxs.CreateSharedLink(arg)
-	if err != nil {
-		apiError, ok := err.(dropbox.APIError)
-		if ok && strings.HasPrefix(apiError.ErrorSummary, "shared_link_already_exists") {
-			listArg := sharing.NewListSharedLinksArg()
-			listArg.Path = path
-			links, err := dbxs.ListSharedLinks(listArg)
-			if err != nil {
-				return "", err
-			}
-			if len(links.Links) > 0 {
-				if sl, ok := links.Links[0].(*sharing.SharedLinkMetadata); ok {
-					return sl.Url, nil
-				}
-			}
+// runOnceConfig generates every podcast in cfgFile and writes each to
+// <outDir>/<slug>.xml. outDir, if empty, falls back to the config's own
+// out_dir, then to ".".
+func runOnceConfig(cfgFile *config.File, outDir string, b backend.Backend) {
+	if outDir == "" {
+		outDir = cfgFile.OutDir
+	}
+	if outDir == "" {
+		outDir = "."
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		log.Fatalf("Failed to create -out-dir %s: %s", outDir, err)
+	}
+
+	for _, podcast := range cfgFile.Podcasts {
+		xmlData, err := feed.Generate(b, podcast.FeedConfig())
+		if err != nil {
+			log.Printf("Failed to generate feed %q: %s", podcast.Slug, err)
+			continue
+		}
+		outPath := filepath.Join(outDir, podcast.Slug+".xml")
+		if err := os.WriteFile(outPath, xmlData, 0o644); err != nil {
+			log.Printf("Failed to write %s: %s", outPath, err)
+			continue
 		}
-		return "", err
+		log.Printf("Wrote %s (%d bytes)", outPath, len(xmlData))
 	}
-	return link.Url, nil
 }
 
-func getAudioDurationFromDropbox(dbxf files.Client, path string) (time.Duration, error) {
-	downloadArg := files.NewDownloadArg(path)
-	_, content, err := dbxf.Download(downloadArg)
-	if err != nil {
-		return 0, err
+// runServe starts the long-running HTTP server that serves the feed(s)
+// and proxies audio requests, regenerating them on an interval or on
+// Dropbox webhook notification. With a single-podcast shorthand command
+// line the feed is served at /feed.xml, as before; with -config each
+// podcast is served at /feeds/{slug}.xml instead.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("dircast serve", flag.ExitOnError)
+	artworkDir := fs.String("artwork-dir", "artwork", "directory to write per-episode cover art extracted from file tags; served from <baseURL>/<artwork-dir>/")
+	statePath := fs.String("state", "dircast-state.json", "path to the state cache file used to avoid re-processing unchanged files on subsequent runs")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	interval := fs.Duration("interval", 0, "how often to regenerate the feed(s) in the background (0 disables the timer; the webhook and first request still trigger a regeneration)")
+	webhookSecret := fs.String("webhook-secret", os.Getenv("DROPBOX_APP_SECRET"), "app secret used to verify the Dropbox webhook signature (defaults to DROPBOX_APP_SECRET)")
+	recursive := fs.Bool("recursive", false, "list sourcePath's subfolders too, mapping them onto itunes:season/itunes:episode numbers")
+	configPath := fs.String("config", "", "path to a YAML config declaring one or more podcasts, instead of the <sourcePath> <baseURL> <imageURL> shorthand")
+	bf := registerBackendFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dircast serve [flags] <sourcePath> <baseURL> <imageURL>")
+		fmt.Fprintln(os.Stderr, "       dircast serve [flags] -config <path>")
+		fmt.Fprintln(os.Stderr, "Refresh token must be set via environment variable DROPBOX_REFRESH_TOKEN or will prompt with interactive flow if unset.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var cfgFile *config.File
+	if *configPath != "" {
+		var err error
+		cfgFile, err = config.Load(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %s", err)
+		}
 	}
-	defer content.Close()
+	applyConfigBackend(fs, bf, cfgFile)
 
-	tmpfile, err := ioutil.TempFile("", "dircast-*")
+	b, err := bf.build()
 	if err != nil {
-		return 0, err
+		log.Fatalf("Failed to set up backend: %s", err)
 	}
-	defer os.Remove(tmpfile.Name())
 
-	data, err := ioutil.ReadAll(content)
-	if err != nil {
-		return 0, err
+	var feeds []feed.Config
+	if cfgFile != nil {
+		for _, podcast := range cfgFile.Podcasts {
+			feeds = append(feeds, podcast.FeedConfig())
+		}
+	} else {
+		if fs.NArg() < 3 {
+			fs.Usage()
+			os.Exit(1)
+		}
+		feeds = []feed.Config{{
+			SourcePath:  strings.TrimSuffix(fs.Arg(0), "/"),
+			BaseURL:     fs.Arg(1),
+			ImageURL:    fs.Arg(2),
+			ArtworkDir:  *artworkDir,
+			StatePath:   *statePath,
+			Recursive:   *recursive,
+			Title:       "Reilly's Awesome Podcast",
+			Author:      "Reilly Watson",
+			Description: "It's Reilly's Podcast, Baby!",
+		}}
+	}
+
+	srv := server.New(server.Config{
+		Addr:          *addr,
+		Interval:      *interval,
+		WebhookSecret: *webhookSecret,
+		Feeds:         feeds,
+		Backend:       b,
+	})
+	log.Printf("Listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("Server stopped: %s", err)
 	}
+}
+
+// backendFlags holds the flags for every supported backend; only the
+// ones relevant to -backend's value are actually used.
+type backendFlags struct {
+	kind *string
 
-	if _, err := tmpfile.Write(data); err != nil {
-		return 0, err
+	s3Bucket   *string
+	s3Region   *string
+	s3Endpoint *string
+
+	webdavURL  *string
+	webdavUser *string
+	webdavPass *string
+
+	localDir  *string
+	localAddr *string
+}
+
+// registerBackendFlags adds -backend and every backend-specific flag to
+// fs. Call build after fs.Parse to construct the selected backend.Backend.
+func registerBackendFlags(fs *flag.FlagSet) *backendFlags {
+	return &backendFlags{
+		kind:       fs.String("backend", "dropbox", "where to read episodes from: dropbox, s3, local, or webdav"),
+		s3Bucket:   fs.String("s3-bucket", "", "S3 backend: bucket name"),
+		s3Region:   fs.String("s3-region", "", "S3 backend: AWS region"),
+		s3Endpoint: fs.String("s3-endpoint", "", "S3 backend: endpoint override, for S3-compatible services"),
+		webdavURL:  fs.String("webdav-url", "", "WebDAV backend: base URL of the share"),
+		webdavUser: fs.String("webdav-user", "", "WebDAV backend: basic-auth username"),
+		webdavPass: fs.String("webdav-pass", os.Getenv("WEBDAV_PASSWORD"), "WebDAV backend: basic-auth password (defaults to WEBDAV_PASSWORD)"),
+		localDir:   fs.String("local-dir", "", "local backend: directory to serve episodes from"),
+		localAddr:  fs.String("local-addr", ":8081", "local backend: address its embedded file server listens on"),
 	}
+}
 
-	cmd := exec.Command("ffprobe", "-v", "error", "-show_format", "-of", "json", tmpfile.Name())
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
+// applyConfigBackend lets cfgFile.Backend act as the default for
+// -backend, without overriding an explicit -backend flag on the command
+// line. A no-op if cfgFile is nil or doesn't set Backend.
+func applyConfigBackend(fs *flag.FlagSet, bf *backendFlags, cfgFile *config.File) {
+	if cfgFile == nil || cfgFile.Backend == "" {
+		return
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "backend" {
+			explicit = true
+		}
+	})
+	if !explicit {
+		*bf.kind = cfgFile.Backend
+	}
+}
+
+// build constructs the backend.Backend selected by -backend, or exits
+// the process on failure for the Dropbox backend's own OAuth errors.
+func (f *backendFlags) build() (backend.Backend, error) {
+	switch *f.kind {
+	case "dropbox":
+		dbxf, dbxs := mustDropboxClients()
+		return backend.NewDropbox(dbxf, dbxs), nil
+	case "s3":
+		if *f.s3Bucket == "" {
+			return nil, fmt.Errorf("-s3-bucket is required for -backend=s3")
+		}
+		return backend.NewS3(context.Background(), backend.S3Config{
+			Bucket:   *f.s3Bucket,
+			Region:   *f.s3Region,
+			Endpoint: *f.s3Endpoint,
+		})
+	case "webdav":
+		if *f.webdavURL == "" {
+			return nil, fmt.Errorf("-webdav-url is required for -backend=webdav")
+		}
+		return backend.NewWebDAV(backend.WebDAVConfig{
+			BaseURL:  *f.webdavURL,
+			Username: *f.webdavUser,
+			Password: *f.webdavPass,
+		})
+	case "local":
+		if *f.localDir == "" {
+			return nil, fmt.Errorf("-local-dir is required for -backend=local")
+		}
+		return backend.NewLocal(*f.localDir, *f.localAddr, "http://localhost"+*f.localAddr)
+	default:
+		return nil, fmt.Errorf("unknown -backend %q (want dropbox, s3, local, or webdav)", *f.kind)
 	}
+}
 
-	var ffprobeOutput FFProbeOutput
-	if err := json.Unmarshal(output, &ffprobeOutput); err != nil {
-		return 0, err
+// mustDropboxClients exchanges the configured refresh token for an
+// access token and builds the Dropbox files/sharing API clients, or
+// exits the process on failure.
+func mustDropboxClients() (files.Client, sharing.Client) {
+	refreshToken := os.Getenv("DROPBOX_REFRESH_TOKEN")
+	if refreshToken == "" || refreshToken == "-" {
+		var err error
+		refreshToken, err = interactiveAuthFlow()
+		if err != nil {
+			log.Fatalf("OAuth flow failed: %v", err)
+		}
+		log.Printf("Obtained refresh token. Store this securely and set DROPBOX_REFRESH_TOKEN to avoid interactive prompts: %s", refreshToken)
 	}
 
-	duration, err := strconv.ParseFloat(ffprobeOutput.Format.Duration, 64)
+	accessToken, err := fetchAccessToken(refreshToken)
 	if err != nil {
-		return 0, err
+		log.Fatalf("Failed to obtain access token: %v", err)
 	}
 
-	return time.Duration(duration * float64(time.Second)), nil
-}
-
-func formatDuration(d time.Duration) string {
-	d = d.Round(time.Second)
-	h := d / time.Hour
-	d -= h * time.Hour
-	m := d / time.Minute
-	d -= m * time.Minute
-	s := d / time.Second
-	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+	config := dropbox.Config{Token: accessToken}
+	return files.New(config), sharing.New(config)
 }
 
 // interactiveAuthFlow launches an authorization flow if no refresh token was provided.